@@ -0,0 +1,49 @@
+// Command server runs the key/value store's HTTP API.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/basperheim/golang-key-value/internal/service/kvstoreservice"
+	"github.com/basperheim/golang-key-value/internal/storage"
+	"github.com/basperheim/golang-key-value/internal/storage/bolt"
+	"github.com/basperheim/golang-key-value/internal/storage/memory"
+	"github.com/basperheim/golang-key-value/internal/storage/persistent"
+	transporthttp "github.com/basperheim/golang-key-value/internal/transport/http"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "HTTP listen address")
+	backend := flag.String("backend", "memory", `storage backend: "memory", "bolt", or "persistent"`)
+	boltPath := flag.String("bolt-path", "data.db", "bbolt database file path (backend=bolt)")
+	dataDir := flag.String("data-dir", "data", "WAL/snapshot directory (backend=persistent)")
+	flag.Parse()
+
+	store, err := newStore(*backend, *boltPath, *dataDir)
+	if err != nil {
+		log.Fatalf("init %s store: %v", *backend, err)
+	}
+	defer store.Close()
+
+	svc := kvstoreservice.New(store)
+	server := transporthttp.NewServer(svc)
+
+	log.Printf("listening on %s (backend=%s)", *addr, *backend)
+	log.Fatal(http.ListenAndServe(*addr, server.Handler()))
+}
+
+func newStore(backend, boltPath, dataDir string) (storage.Store, error) {
+	switch backend {
+	case "memory":
+		return memory.New(), nil
+	case "bolt":
+		return bolt.Open(boltPath)
+	case "persistent":
+		return persistent.Open(dataDir)
+	default:
+		log.Fatalf("unknown storage backend %q", backend)
+		return nil, nil
+	}
+}
@@ -0,0 +1,52 @@
+// Package apierror defines the structured JSON error envelope returned by
+// every failure path in the HTTP API, along with the stable numeric codes
+// clients can switch on instead of parsing message strings.
+package apierror
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Numeric error codes grouped by failure class. Codes are part of the
+// public API and must not be renumbered once shipped.
+const (
+	CodeMissingKey       = 100
+	CodeMissingValue     = 200
+	CodeInvalidBody      = 201
+	CodeInvalidTTL       = 202
+	CodeKeyNotFound      = 300
+	CodeKeyExists        = 301
+	CodeCASMismatch      = 400
+	CodeMethodNotAllowed = 500
+	CodeInternal         = 501
+	CodeNotImplemented   = 502
+)
+
+// APIError is the JSON body written for every failed request.
+type APIError struct {
+	Code    int    `json:"errorCode"`
+	Message string `json:"message"`
+	Cause   string `json:"cause,omitempty"`
+	Index   uint64 `json:"index,omitempty"`
+}
+
+func (e APIError) Error() string {
+	return e.Message
+}
+
+// Write sets the JSON content type, the given status code, and encodes an
+// APIError body built from code/message/cause. index is the store's
+// current mutation index so a client can correlate the failure with (or
+// resume a watch from) a known point in the event stream; pass 0 if the
+// failure is unrelated to store state.
+func Write(w http.ResponseWriter, status, code int, message, cause string, index uint64) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(APIError{
+		Code:    code,
+		Message: message,
+		Cause:   cause,
+		Index:   index,
+	})
+}
@@ -0,0 +1,59 @@
+package apierror
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestWriteSetsStatusAndContentType verifies that Write sets the JSON
+// content type and the given status code before encoding the body.
+func TestWriteSetsStatusAndContentType(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	Write(rec, 404, CodeKeyNotFound, "Key not found", "key", 7)
+
+	if rec.Code != 404 {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type = %q, want application/json", ct)
+	}
+
+	var got APIError
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	want := APIError{Code: CodeKeyNotFound, Message: "Key not found", Cause: "key", Index: 7}
+	if got != want {
+		t.Fatalf("body = %+v, want %+v", got, want)
+	}
+}
+
+// TestWriteOmitsZeroCauseAndIndex verifies that an empty cause and a zero
+// index are omitted from the JSON body, since they're declared omitempty.
+func TestWriteOmitsZeroCauseAndIndex(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	Write(rec, 500, CodeInternal, "boom", "", 0)
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &raw); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if _, ok := raw["cause"]; ok {
+		t.Fatalf("body unexpectedly has a cause field: %v", raw)
+	}
+	if _, ok := raw["index"]; ok {
+		t.Fatalf("body unexpectedly has an index field: %v", raw)
+	}
+}
+
+// TestAPIErrorError verifies Error() surfaces the message so APIError
+// satisfies the error interface in the obvious way.
+func TestAPIErrorError(t *testing.T) {
+	err := APIError{Message: "something went wrong"}
+	if got := err.Error(); got != "something went wrong" {
+		t.Fatalf("Error() = %q, want %q", got, "something went wrong")
+	}
+}
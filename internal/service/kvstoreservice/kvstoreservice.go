@@ -0,0 +1,152 @@
+// Package kvstoreservice holds the HTTP-independent business logic of the
+// key/value store: request validation (missing key/value, TTL parsing)
+// sits here so the transport layer only has to translate errors to status
+// codes, and so any future transport (gRPC, CLI, ...) can reuse it.
+package kvstoreservice
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/basperheim/golang-key-value/internal/storage"
+)
+
+// Validation errors produced before a request ever reaches the Store.
+var (
+	ErrMissingKey   = errors.New("key not provided")
+	ErrMissingValue = errors.New("value not provided")
+	ErrInvalidTTL   = errors.New("invalid ttl value")
+)
+
+// Service wraps a storage.Store with request validation.
+type Service struct {
+	store storage.Store
+}
+
+// New wraps store with validation logic.
+func New(store storage.Store) *Service {
+	return &Service{store: store}
+}
+
+// ParseTTL parses a Go duration string such as "30s". An empty string
+// means no TTL. It rejects unparseable or negative durations.
+func ParseTTL(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(raw)
+	if err != nil || ttl < 0 {
+		return 0, ErrInvalidTTL
+	}
+	return ttl, nil
+}
+
+// Set validates key/value/ttl and forwards to the store.
+func (s *Service) Set(key, value, ttlRaw string) (storage.Entry, error) {
+	if key == "" {
+		return storage.Entry{}, ErrMissingKey
+	}
+	if value == "" {
+		return storage.Entry{}, ErrMissingValue
+	}
+	ttl, err := ParseTTL(ttlRaw)
+	if err != nil {
+		return storage.Entry{}, err
+	}
+	return s.store.Set(key, value, ttl)
+}
+
+// Get validates key and forwards to the store.
+func (s *Service) Get(key string) (storage.Entry, bool, error) {
+	if key == "" {
+		return storage.Entry{}, false, ErrMissingKey
+	}
+	return s.store.Get(key)
+}
+
+// Delete validates key and forwards to the store.
+func (s *Service) Delete(key string) (bool, error) {
+	if key == "" {
+		return false, ErrMissingKey
+	}
+	return s.store.Delete(key)
+}
+
+// CompareAndSwap validates key/ttl and forwards to the store.
+func (s *Service) CompareAndSwap(key, prevValue, newValue, ttlRaw string) (storage.Entry, bool, error) {
+	if key == "" {
+		return storage.Entry{}, false, ErrMissingKey
+	}
+	ttl, err := ParseTTL(ttlRaw)
+	if err != nil {
+		return storage.Entry{}, false, err
+	}
+	return s.store.CompareAndSwap(key, prevValue, newValue, ttl)
+}
+
+// CompareAndDelete validates key and forwards to the store.
+func (s *Service) CompareAndDelete(key, prevValue string) (bool, error) {
+	if key == "" {
+		return false, ErrMissingKey
+	}
+	return s.store.CompareAndDelete(key, prevValue)
+}
+
+// CreateIfAbsent validates key/ttl and forwards to the store.
+func (s *Service) CreateIfAbsent(key, value, ttlRaw string) (storage.Entry, bool, error) {
+	if key == "" {
+		return storage.Entry{}, false, ErrMissingKey
+	}
+	ttl, err := ParseTTL(ttlRaw)
+	if err != nil {
+		return storage.Entry{}, false, err
+	}
+	return s.store.CreateIfAbsent(key, value, ttl)
+}
+
+// UpdateIfExists validates key/ttl and forwards to the store.
+func (s *Service) UpdateIfExists(key, value, ttlRaw string) (storage.Entry, bool, error) {
+	if key == "" {
+		return storage.Entry{}, false, ErrMissingKey
+	}
+	ttl, err := ParseTTL(ttlRaw)
+	if err != nil {
+		return storage.Entry{}, false, err
+	}
+	return s.store.UpdateIfExists(key, value, ttl)
+}
+
+// Watch validates keyPrefix and forwards to the store.
+func (s *Service) Watch(ctx context.Context, keyPrefix string, recursive bool, sinceIndex uint64) (<-chan storage.Event, error) {
+	if keyPrefix == "" {
+		return nil, ErrMissingKey
+	}
+	return s.store.Watch(ctx, keyPrefix, recursive, sinceIndex)
+}
+
+// Index returns the store's current mutation index.
+func (s *Service) Index() uint64 {
+	return s.store.Index()
+}
+
+// Snapshot triggers an out-of-band snapshot on the underlying store,
+// returning storage.ErrSnapshotUnsupported if it isn't a storage.Snapshotter.
+func (s *Service) Snapshot() (string, uint64, error) {
+	snapshotter, ok := s.store.(storage.Snapshotter)
+	if !ok {
+		return "", 0, storage.ErrSnapshotUnsupported
+	}
+	return snapshotter.Snapshot()
+}
+
+// LatestSnapshotPath returns the path of the underlying store's most
+// recent snapshot, returning storage.ErrSnapshotUnsupported if it isn't a
+// storage.Snapshotter.
+func (s *Service) LatestSnapshotPath() (string, error) {
+	snapshotter, ok := s.store.(storage.Snapshotter)
+	if !ok {
+		return "", storage.ErrSnapshotUnsupported
+	}
+	return snapshotter.LatestSnapshotPath()
+}
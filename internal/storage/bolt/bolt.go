@@ -0,0 +1,360 @@
+// Package bolt implements storage.Store on top of bbolt, giving the
+// key/value store durability across restarts at the cost of per-operation
+// disk I/O.
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/basperheim/golang-key-value/internal/storage"
+)
+
+var (
+	dataBucket = []byte("data")
+	metaBucket = []byte("meta")
+	indexKey   = []byte("index")
+)
+
+// Store is the bbolt-backed storage.Store implementation.
+type Store struct {
+	db     *bolt.DB
+	broker *storage.EventBroker
+	done   chan struct{}
+}
+
+// Open opens (creating if necessary) the bbolt database at path and starts
+// a background sweeper that evicts expired entries.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(dataBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(metaBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &Store{db: db, broker: storage.NewEventBroker(), done: make(chan struct{})}
+	go s.expiryLoop()
+	return s, nil
+}
+
+// Close stops the background sweeper and closes the underlying database.
+func (s *Store) Close() error {
+	close(s.done)
+	return s.db.Close()
+}
+
+// Index returns the store's current monotonically increasing mutation
+// index, persisted in the meta bucket.
+func (s *Store) Index() uint64 {
+	var idx uint64
+	s.db.View(func(tx *bolt.Tx) error {
+		idx = readIndex(tx)
+		return nil
+	})
+	return idx
+}
+
+func readIndex(tx *bolt.Tx) uint64 {
+	raw := tx.Bucket(metaBucket).Get(indexKey)
+	if len(raw) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(raw)
+}
+
+// nextIndex bumps and persists the mutation index within tx, returning the
+// new value. Callers must be inside a writable transaction.
+func nextIndex(tx *bolt.Tx) uint64 {
+	idx := readIndex(tx) + 1
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, idx)
+	tx.Bucket(metaBucket).Put(indexKey, buf)
+	return idx
+}
+
+func getEntry(tx *bolt.Tx, key string) (storage.Entry, bool, error) {
+	raw := tx.Bucket(dataBucket).Get([]byte(key))
+	if raw == nil {
+		return storage.Entry{}, false, nil
+	}
+	var entry storage.Entry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return storage.Entry{}, false, err
+	}
+	return entry, true, nil
+}
+
+func putEntry(tx *bolt.Tx, key string, entry storage.Entry) error {
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(dataBucket).Put([]byte(key), raw)
+}
+
+func deleteEntry(tx *bolt.Tx, key string) error {
+	return tx.Bucket(dataBucket).Delete([]byte(key))
+}
+
+// Set creates or overwrites key's entry. A ttl of zero or less means the
+// entry never expires.
+func (s *Store) Set(key, value string, ttl time.Duration) (storage.Entry, error) {
+	var entry storage.Entry
+	var idx uint64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		idx = nextIndex(tx)
+		now := time.Now()
+		entry = storage.Entry{Key: key, Value: value, CreatedAt: now, UpdatedAt: now}
+		if ttl > 0 {
+			expireAt := now.Add(ttl)
+			entry.ExpireTime = &expireAt
+		}
+		return putEntry(tx, key, entry)
+	})
+	if err != nil {
+		return storage.Entry{}, err
+	}
+	s.publish(storage.EventSet, key, value, "", idx)
+	return entry, nil
+}
+
+// Get retrieves key's entry, transparently evicting it if its TTL has
+// already passed. Reads run in a View transaction so concurrent GETs don't
+// serialize against each other or against writers; only an expired entry
+// escalates to an Update to evict it.
+func (s *Store) Get(key string) (storage.Entry, bool, error) {
+	var entry storage.Entry
+	var found, expired bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		e, ok, err := getEntry(tx, key)
+		if err != nil || !ok {
+			return err
+		}
+		if e.Expired(time.Now()) {
+			expired = true
+			return nil
+		}
+		entry, found = e, true
+		return nil
+	})
+	if err != nil {
+		return storage.Entry{}, false, err
+	}
+	if expired {
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			return deleteEntry(tx, key)
+		})
+		return storage.Entry{}, false, err
+	}
+	return entry, found, nil
+}
+
+// Delete removes a key from the store.
+func (s *Store) Delete(key string) (bool, error) {
+	var existed bool
+	var prevValue string
+	var idx uint64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		e, ok, err := getEntry(tx, key)
+		if err != nil || !ok {
+			return err
+		}
+		existed = true
+		prevValue = e.Value
+		idx = nextIndex(tx)
+		return deleteEntry(tx, key)
+	})
+	if err != nil || !existed {
+		return existed, err
+	}
+	s.publish(storage.EventDelete, key, "", prevValue, idx)
+	return true, nil
+}
+
+// CompareAndSwap sets key to newValue only if its current value equals
+// prevValue, returning storage.ErrKeyNotFound if the key is absent or
+// storage.ErrPreconditionFailed if prevValue does not match.
+func (s *Store) CompareAndSwap(key, prevValue, newValue string, ttl time.Duration) (storage.Entry, bool, error) {
+	var entry storage.Entry
+	var idx uint64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		current, ok, err := getEntry(tx, key)
+		if err != nil {
+			return err
+		}
+		if !ok || current.Expired(time.Now()) {
+			return storage.ErrKeyNotFound
+		}
+		if current.Value != prevValue {
+			return storage.ErrPreconditionFailed
+		}
+		idx = nextIndex(tx)
+		now := time.Now()
+		entry = storage.Entry{Key: key, Value: newValue, CreatedAt: current.CreatedAt, UpdatedAt: now}
+		if ttl > 0 {
+			expireAt := now.Add(ttl)
+			entry.ExpireTime = &expireAt
+		}
+		return putEntry(tx, key, entry)
+	})
+	if err != nil {
+		return storage.Entry{}, false, err
+	}
+	s.publish(storage.EventCAS, key, newValue, prevValue, idx)
+	return entry, true, nil
+}
+
+// CompareAndDelete removes key only if its current value equals prevValue,
+// returning storage.ErrKeyNotFound if the key is absent or
+// storage.ErrPreconditionFailed if prevValue does not match.
+func (s *Store) CompareAndDelete(key, prevValue string) (bool, error) {
+	var idx uint64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		current, ok, err := getEntry(tx, key)
+		if err != nil {
+			return err
+		}
+		if !ok || current.Expired(time.Now()) {
+			return storage.ErrKeyNotFound
+		}
+		if current.Value != prevValue {
+			return storage.ErrPreconditionFailed
+		}
+		idx = nextIndex(tx)
+		return deleteEntry(tx, key)
+	})
+	if err != nil {
+		return false, err
+	}
+	s.publish(storage.EventDelete, key, "", prevValue, idx)
+	return true, nil
+}
+
+// CreateIfAbsent sets key to value only if it does not already exist,
+// returning storage.ErrKeyExists otherwise.
+func (s *Store) CreateIfAbsent(key, value string, ttl time.Duration) (storage.Entry, bool, error) {
+	var entry storage.Entry
+	var idx uint64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		current, ok, err := getEntry(tx, key)
+		if err != nil {
+			return err
+		}
+		if ok && !current.Expired(time.Now()) {
+			return storage.ErrKeyExists
+		}
+		idx = nextIndex(tx)
+		now := time.Now()
+		entry = storage.Entry{Key: key, Value: value, CreatedAt: now, UpdatedAt: now}
+		if ttl > 0 {
+			expireAt := now.Add(ttl)
+			entry.ExpireTime = &expireAt
+		}
+		return putEntry(tx, key, entry)
+	})
+	if err != nil {
+		return storage.Entry{}, false, err
+	}
+	s.publish(storage.EventCAS, key, value, "", idx)
+	return entry, true, nil
+}
+
+// UpdateIfExists sets key to value only if it already exists, returning
+// storage.ErrKeyNotFound otherwise.
+func (s *Store) UpdateIfExists(key, value string, ttl time.Duration) (storage.Entry, bool, error) {
+	var entry storage.Entry
+	var idx uint64
+	var prevValue string
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		current, ok, err := getEntry(tx, key)
+		if err != nil {
+			return err
+		}
+		if !ok || current.Expired(time.Now()) {
+			return storage.ErrKeyNotFound
+		}
+		prevValue = current.Value
+		idx = nextIndex(tx)
+		now := time.Now()
+		entry = storage.Entry{Key: key, Value: value, CreatedAt: current.CreatedAt, UpdatedAt: now}
+		if ttl > 0 {
+			expireAt := now.Add(ttl)
+			entry.ExpireTime = &expireAt
+		}
+		return putEntry(tx, key, entry)
+	})
+	if err != nil {
+		return storage.Entry{}, false, err
+	}
+	s.publish(storage.EventCAS, key, value, prevValue, idx)
+	return entry, true, nil
+}
+
+// Watch subscribes to key mutations; see storage.EventBroker.Watch.
+func (s *Store) Watch(ctx context.Context, keyPrefix string, recursive bool, sinceIndex uint64) (<-chan storage.Event, error) {
+	return s.broker.Watch(ctx, keyPrefix, recursive, sinceIndex)
+}
+
+func (s *Store) publish(action storage.EventAction, key, value, prevValue string, index uint64) {
+	s.broker.Publish(storage.Event{
+		Action:    action,
+		Key:       key,
+		Value:     value,
+		PrevValue: prevValue,
+		Index:     index,
+		CreatedAt: time.Now(),
+	})
+}
+
+// expiryLoop periodically scans the database for expired entries and
+// evicts them. A full scan is simple and acceptable at this store's scale;
+// unlike memory.Store there is no in-memory heap to index expirations by
+// deadline.
+func (s *Store) expiryLoop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sweepExpired()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *Store) sweepExpired() {
+	now := time.Now()
+	var expiredKeys []string
+	s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(dataBucket).ForEach(func(k, v []byte) error {
+			var entry storage.Entry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return nil
+			}
+			if entry.Expired(now) {
+				expiredKeys = append(expiredKeys, string(k))
+			}
+			return nil
+		})
+	})
+	for _, key := range expiredKeys {
+		s.db.Update(func(tx *bolt.Tx) error {
+			return deleteEntry(tx, key)
+		})
+	}
+}
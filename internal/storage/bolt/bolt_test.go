@@ -0,0 +1,134 @@
+package bolt
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/basperheim/golang-key-value/internal/storage"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// TestCompareAndSwap verifies the happy path, a value mismatch, and a
+// missing key each return the documented result.
+func TestCompareAndSwap(t *testing.T) {
+	s := openTestStore(t)
+
+	if _, err := s.Set("k", "v1", 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	entry, ok, err := s.CompareAndSwap("k", "v1", "v2", 0)
+	if err != nil || !ok || entry.Value != "v2" {
+		t.Fatalf("CompareAndSwap(match) = (%+v, %v, %v), want (\"v2\", true, nil)", entry, ok, err)
+	}
+
+	if _, ok, err := s.CompareAndSwap("k", "v1", "v3", 0); ok || !errors.Is(err, storage.ErrPreconditionFailed) {
+		t.Fatalf("CompareAndSwap(stale prevValue) = (ok=%v, err=%v), want (false, ErrPreconditionFailed)", ok, err)
+	}
+
+	if _, ok, err := s.CompareAndSwap("missing", "v1", "v2", 0); ok || !errors.Is(err, storage.ErrKeyNotFound) {
+		t.Fatalf("CompareAndSwap(missing key) = (ok=%v, err=%v), want (false, ErrKeyNotFound)", ok, err)
+	}
+}
+
+// TestCompareAndDelete verifies the happy path, a value mismatch, and a
+// missing key each return the documented result.
+func TestCompareAndDelete(t *testing.T) {
+	s := openTestStore(t)
+
+	if _, err := s.Set("k", "v1", 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	if ok, err := s.CompareAndDelete("k", "wrong"); ok || !errors.Is(err, storage.ErrPreconditionFailed) {
+		t.Fatalf("CompareAndDelete(mismatch) = (ok=%v, err=%v), want (false, ErrPreconditionFailed)", ok, err)
+	}
+
+	ok, err := s.CompareAndDelete("k", "v1")
+	if err != nil || !ok {
+		t.Fatalf("CompareAndDelete(match) = (%v, %v), want (true, nil)", ok, err)
+	}
+
+	if ok, err := s.CompareAndDelete("k", "v1"); ok || !errors.Is(err, storage.ErrKeyNotFound) {
+		t.Fatalf("CompareAndDelete(already gone) = (ok=%v, err=%v), want (false, ErrKeyNotFound)", ok, err)
+	}
+}
+
+// TestCreateIfAbsent verifies that it succeeds once and then reports
+// ErrKeyExists on a second call for the same key.
+func TestCreateIfAbsent(t *testing.T) {
+	s := openTestStore(t)
+
+	entry, ok, err := s.CreateIfAbsent("k", "v1", 0)
+	if err != nil || !ok || entry.Value != "v1" {
+		t.Fatalf("CreateIfAbsent(absent) = (%+v, %v, %v), want (\"v1\", true, nil)", entry, ok, err)
+	}
+
+	if _, ok, err := s.CreateIfAbsent("k", "v2", 0); ok || !errors.Is(err, storage.ErrKeyExists) {
+		t.Fatalf("CreateIfAbsent(already present) = (ok=%v, err=%v), want (false, ErrKeyExists)", ok, err)
+	}
+}
+
+// TestUpdateIfExists verifies that it reports ErrKeyNotFound for an absent
+// key and otherwise overwrites the value.
+func TestUpdateIfExists(t *testing.T) {
+	s := openTestStore(t)
+
+	if _, ok, err := s.UpdateIfExists("missing", "v", 0); ok || !errors.Is(err, storage.ErrKeyNotFound) {
+		t.Fatalf("UpdateIfExists(absent) = (ok=%v, err=%v), want (false, ErrKeyNotFound)", ok, err)
+	}
+
+	if _, err := s.Set("k", "v1", 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	entry, ok, err := s.UpdateIfExists("k", "v2", 0)
+	if err != nil || !ok || entry.Value != "v2" {
+		t.Fatalf("UpdateIfExists(present) = (%+v, %v, %v), want (\"v2\", true, nil)", entry, ok, err)
+	}
+}
+
+// TestCompareAndSwapConcurrentOnlyOneWinner verifies that when many
+// goroutines race a CompareAndSwap against the same expected prevValue,
+// exactly one succeeds and the rest observe a precondition failure - bbolt's
+// single-writer transactions must serialize the check-and-set.
+func TestCompareAndSwapConcurrentOnlyOneWinner(t *testing.T) {
+	s := openTestStore(t)
+
+	if _, err := s.Set("k", "initial", 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	const n = 50
+	var successes int32
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			if _, ok, _ := s.CompareAndSwap("k", "initial", "updated", 0); ok {
+				atomic.AddInt32(&successes, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if successes != 1 {
+		t.Fatalf("successful CompareAndSwap count = %d, want exactly 1", successes)
+	}
+	entry, ok, err := s.Get("k")
+	if err != nil || !ok || entry.Value != "updated" {
+		t.Fatalf("Get after race = (%+v, %v, %v), want (\"updated\", true, nil)", entry, ok, err)
+	}
+}
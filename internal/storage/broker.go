@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// EventRingSize bounds how many recent events a newly connecting watcher
+// can replay via "since"; older events are no longer retrievable.
+const EventRingSize = 1024
+
+// eventRing is a fixed-size ring buffer of the most recent events, indexed
+// by their monotonic Event.Index (assigned 1, 2, 3, ... by the caller, so
+// slot = (index-1) % len(buf)).
+type eventRing struct {
+	buf   [EventRingSize]Event
+	count uint64
+}
+
+func (r *eventRing) push(e Event) {
+	r.buf[(e.Index-1)%EventRingSize] = e
+	r.count++
+}
+
+// since returns the retained events with Index > sinceIndex, oldest first.
+func (r *eventRing) since(sinceIndex uint64) []Event {
+	if r.count == 0 {
+		return nil
+	}
+	oldest := uint64(0)
+	if r.count > EventRingSize {
+		oldest = r.count - EventRingSize
+	}
+	start := sinceIndex
+	if start < oldest {
+		start = oldest
+	}
+	events := make([]Event, 0, r.count-start)
+	for i := start; i < r.count; i++ {
+		events = append(events, r.buf[i%EventRingSize])
+	}
+	return events
+}
+
+// subscriber is a live Watch connection waiting for events matching
+// keyPrefix (an exact key match unless recursive is set).
+type subscriber struct {
+	ch        chan Event
+	keyPrefix string
+	recursive bool
+}
+
+func (s *subscriber) matches(e Event) bool {
+	if s.recursive {
+		return strings.HasPrefix(e.Key, s.keyPrefix)
+	}
+	return e.Key == s.keyPrefix
+}
+
+// EventBroker retains a bounded backlog of events and fans out live ones
+// to matching watchers. Store implementations embed one and call Publish
+// once per mutation under their own data lock; Watch itself is safe to
+// call concurrently with Publish.
+type EventBroker struct {
+	mu   sync.Mutex
+	ring eventRing
+	subs map[*subscriber]struct{}
+}
+
+// NewEventBroker returns a ready-to-use EventBroker.
+func NewEventBroker() *EventBroker {
+	return &EventBroker{subs: make(map[*subscriber]struct{})}
+}
+
+// Publish records e and delivers it to every matching subscriber. Slow
+// subscribers are skipped rather than blocking the caller.
+func (b *EventBroker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ring.push(e)
+	for sub := range b.subs {
+		if !sub.matches(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+		}
+	}
+}
+
+// Watch subscribes to events for keyPrefix (an exact key match unless
+// recursive is true), first replaying any retained events after
+// sinceIndex and then streaming live ones. The returned channel is closed
+// once ctx is done.
+func (b *EventBroker) Watch(ctx context.Context, keyPrefix string, recursive bool, sinceIndex uint64) (<-chan Event, error) {
+	sub := &subscriber{ch: make(chan Event, 64), keyPrefix: keyPrefix, recursive: recursive}
+
+	b.mu.Lock()
+	var backlog []Event
+	for _, e := range b.ring.since(sinceIndex) {
+		if sub.matches(e) {
+			backlog = append(backlog, e)
+		}
+	}
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	// Backlog is placed on out synchronously, before Watch returns, so a
+	// caller that immediately does a non-blocking receive (e.g. wait=false)
+	// still observes it; only the live phase needs a goroutine.
+	out := make(chan Event, len(backlog)+64)
+	for _, e := range backlog {
+		out <- e
+	}
+
+	go func() {
+		defer func() {
+			b.mu.Lock()
+			delete(b.subs, sub)
+			b.mu.Unlock()
+			close(out)
+		}()
+
+		for {
+			select {
+			case e, ok := <-sub.ch:
+				if !ok {
+					return
+				}
+				select {
+				case out <- e:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
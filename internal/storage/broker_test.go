@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+// TestWatchDeliversBacklogBeforeReturning verifies that a matching backlog
+// event is available on the returned channel as soon as Watch returns, so
+// a caller doing a non-blocking receive (the wait=false HTTP case) sees it
+// without having to wait for an internal forwarding goroutine to be
+// scheduled.
+func TestWatchDeliversBacklogBeforeReturning(t *testing.T) {
+	b := NewEventBroker()
+	b.Publish(Event{Key: "k", Index: 1})
+
+	events, err := b.Watch(context.Background(), "k", false, 0)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatal("events channel closed before yielding the backlog event")
+		}
+		if e.Index != 1 {
+			t.Fatalf("Index = %d, want 1", e.Index)
+		}
+	default:
+		t.Fatal("non-blocking receive found no backlog event immediately after Watch returned")
+	}
+}
@@ -0,0 +1,388 @@
+// Package memory implements storage.Store as an in-process map. It is the
+// original (and default) backend: fast, but its contents do not survive a
+// restart.
+package memory
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/basperheim/golang-key-value/internal/storage"
+)
+
+// expiryItem is a single entry in the expiry heap. generation is compared
+// against Store.generation at pop time so a Set or Delete that supersedes
+// this entry can be recognized and skipped, since container/heap has no
+// cheap way to remove an arbitrary element.
+type expiryItem struct {
+	key        string
+	expireAt   time.Time
+	generation uint64
+}
+
+// expiryHeap is a min-heap of expiryItem ordered by expireAt.
+type expiryHeap []*expiryItem
+
+func (h expiryHeap) Len() int            { return len(h) }
+func (h expiryHeap) Less(i, j int) bool  { return h[i].expireAt.Before(h[j].expireAt) }
+func (h expiryHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x interface{}) { *h = append(*h, x.(*expiryItem)) }
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+// Store is the in-memory storage.Store implementation.
+type Store struct {
+	data map[string]storage.Entry
+	// generation tracks, per key, the count used to invalidate stale heap
+	// entries (see expiryItem). pending tracks how many not-yet-popped
+	// heap entries still reference each key; once it reaches zero for a
+	// key that's no longer in data, the key's generation entry is pruned,
+	// since nothing can collide with reassigning it afterwards.
+	generation map[string]uint64
+	pending    map[string]int
+	expiry     expiryHeap
+	wake       chan struct{}
+	done       chan struct{}
+	closeOnce  sync.Once
+	index      uint64
+	broker     *storage.EventBroker
+	mu         sync.RWMutex
+}
+
+// New creates a Store and starts its background expiry sweeper.
+func New() *Store {
+	s := &Store{
+		data:       make(map[string]storage.Entry),
+		generation: make(map[string]uint64),
+		pending:    make(map[string]int),
+		wake:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+		broker:     storage.NewEventBroker(),
+	}
+	go s.expiryLoop()
+	return s
+}
+
+// Close stops the background expiry sweeper.
+func (s *Store) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	return nil
+}
+
+// Index returns the store's current monotonically increasing mutation
+// index, bumped once per Set/Delete/CAS so clients can correlate
+// responses and resume watches.
+func (s *Store) Index() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index
+}
+
+// Entries returns a snapshot copy of every non-expired entry currently
+// held, for durable wrappers (e.g. storage/persistent) that need to
+// serialize the store's full contents.
+func (s *Store) Entries() []storage.Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	entries := make([]storage.Entry, 0, len(s.data))
+	for _, e := range s.data {
+		if e.Expired(now) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// Restore replaces the store's contents and mutation index wholesale,
+// without publishing events. It is meant for durable wrappers (e.g.
+// storage/persistent) reconstructing state from a snapshot and WAL replay
+// at startup, before the store is exposed to any client.
+func (s *Store) Restore(entries []storage.Entry, index uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data = make(map[string]storage.Entry, len(entries))
+	s.generation = make(map[string]uint64, len(entries))
+	s.pending = make(map[string]int, len(entries))
+	s.expiry = nil
+	for _, e := range entries {
+		s.data[e.Key] = e
+		if e.ExpireTime != nil {
+			s.generation[e.Key]++
+			s.pending[e.Key]++
+			heap.Push(&s.expiry, &expiryItem{key: e.Key, expireAt: *e.ExpireTime, generation: s.generation[e.Key]})
+		}
+	}
+	s.index = index
+	s.wakeLocked()
+}
+
+// Set creates or overwrites key's entry. A ttl of zero or less means the
+// entry never expires.
+func (s *Store) Set(key, value string, ttl time.Duration) (storage.Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.setLocked(key, value, ttl)
+	s.publishLocked(storage.EventSet, key, value, "")
+	return entry, nil
+}
+
+// setLocked creates or overwrites key's entry. Callers must hold s.mu for
+// writing.
+func (s *Store) setLocked(key, value string, ttl time.Duration) storage.Entry {
+	now := time.Now()
+	s.index++
+	entry := storage.Entry{
+		Key:       key,
+		Value:     value,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	s.generation[key]++
+	if ttl > 0 {
+		expireAt := now.Add(ttl)
+		entry.ExpireTime = &expireAt
+		heap.Push(&s.expiry, &expiryItem{key: key, expireAt: expireAt, generation: s.generation[key]})
+		s.pending[key]++
+		s.wakeLocked()
+	}
+
+	s.data[key] = entry
+	return entry
+}
+
+// getLocked returns key's entry, deleting and reporting it missing if its
+// TTL has already passed. Callers must hold s.mu for writing.
+func (s *Store) getLocked(key string) (storage.Entry, bool) {
+	entry, ok := s.data[key]
+	if !ok {
+		return storage.Entry{}, false
+	}
+	if entry.Expired(time.Now()) {
+		delete(s.data, key)
+		return storage.Entry{}, false
+	}
+	return entry, true
+}
+
+// Get retrieves a value from the store based on the given key. An entry
+// whose TTL has passed is treated as missing even if the background
+// sweeper has not collected it yet.
+func (s *Store) Get(key string) (storage.Entry, bool, error) {
+	s.mu.RLock()
+	entry, ok := s.data[key]
+	s.mu.RUnlock()
+	if !ok {
+		return storage.Entry{}, false, nil
+	}
+	if entry.Expired(time.Now()) {
+		s.mu.Lock()
+		// Re-check under the write lock in case a Set raced with us.
+		if current, stillThere := s.data[key]; stillThere && current.Expired(time.Now()) {
+			delete(s.data, key)
+		}
+		s.mu.Unlock()
+		return storage.Entry{}, false, nil
+	}
+	return entry, true, nil
+}
+
+// Delete removes a key from the store.
+func (s *Store) Delete(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.getLocked(key)
+	if !ok {
+		return false, nil
+	}
+	s.index++
+	s.generation[key]++
+	delete(s.data, key)
+	s.pruneGenerationLocked(key)
+	s.publishLocked(storage.EventDelete, key, "", prev.Value)
+	return true, nil
+}
+
+// CompareAndSwap sets key to newValue only if its current value equals
+// prevValue, returning storage.ErrKeyNotFound if the key is absent or
+// storage.ErrPreconditionFailed if prevValue does not match.
+func (s *Store) CompareAndSwap(key, prevValue, newValue string, ttl time.Duration) (storage.Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.getLocked(key)
+	if !ok {
+		return storage.Entry{}, false, storage.ErrKeyNotFound
+	}
+	if entry.Value != prevValue {
+		return entry, false, storage.ErrPreconditionFailed
+	}
+	newEntry := s.setLocked(key, newValue, ttl)
+	s.publishLocked(storage.EventCAS, key, newValue, prevValue)
+	return newEntry, true, nil
+}
+
+// CompareAndDelete removes key only if its current value equals prevValue,
+// returning storage.ErrKeyNotFound if the key is absent or
+// storage.ErrPreconditionFailed if prevValue does not match.
+func (s *Store) CompareAndDelete(key, prevValue string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.getLocked(key)
+	if !ok {
+		return false, storage.ErrKeyNotFound
+	}
+	if entry.Value != prevValue {
+		return false, storage.ErrPreconditionFailed
+	}
+	s.index++
+	s.generation[key]++
+	delete(s.data, key)
+	s.pruneGenerationLocked(key)
+	s.publishLocked(storage.EventDelete, key, "", entry.Value)
+	return true, nil
+}
+
+// CreateIfAbsent sets key to value only if it does not already exist,
+// returning storage.ErrKeyExists otherwise.
+func (s *Store) CreateIfAbsent(key, value string, ttl time.Duration) (storage.Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.getLocked(key); ok {
+		return storage.Entry{}, false, storage.ErrKeyExists
+	}
+	entry := s.setLocked(key, value, ttl)
+	s.publishLocked(storage.EventCAS, key, value, "")
+	return entry, true, nil
+}
+
+// UpdateIfExists sets key to value only if it already exists, returning
+// storage.ErrKeyNotFound otherwise.
+func (s *Store) UpdateIfExists(key, value string, ttl time.Duration) (storage.Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := s.getLocked(key)
+	if !ok {
+		return storage.Entry{}, false, storage.ErrKeyNotFound
+	}
+	entry := s.setLocked(key, value, ttl)
+	s.publishLocked(storage.EventCAS, key, value, prev.Value)
+	return entry, true, nil
+}
+
+// Watch subscribes to key mutations; see storage.EventBroker.Watch.
+func (s *Store) Watch(ctx context.Context, keyPrefix string, recursive bool, sinceIndex uint64) (<-chan storage.Event, error) {
+	return s.broker.Watch(ctx, keyPrefix, recursive, sinceIndex)
+}
+
+// publishLocked publishes an event for the mutation that just happened.
+// Callers must hold s.mu and must have already bumped s.index.
+func (s *Store) publishLocked(action storage.EventAction, key, value, prevValue string) {
+	s.broker.Publish(storage.Event{
+		Action:    action,
+		Key:       key,
+		Value:     value,
+		PrevValue: prevValue,
+		Index:     s.index,
+		CreatedAt: time.Now(),
+	})
+}
+
+// wakeLocked signals the expiry loop that a sooner deadline may now be
+// pending. Callers must hold s.mu.
+func (s *Store) wakeLocked() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// expiryLoop waits for the soonest pending expiration and sweeps it. It
+// runs until Close is called.
+func (s *Store) expiryLoop() {
+	timer := time.NewTimer(time.Hour)
+	if !timer.Stop() {
+		<-timer.C
+	}
+
+	for {
+		s.mu.RLock()
+		wait := time.Hour
+		if len(s.expiry) > 0 {
+			if d := time.Until(s.expiry[0].expireAt); d > 0 {
+				wait = d
+			} else {
+				wait = 0
+			}
+		}
+		s.mu.RUnlock()
+
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+			s.sweepExpired()
+		case <-s.wake:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		case <-s.done:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// sweepExpired pops and deletes every heap entry whose deadline has
+// passed, discarding any that were superseded by a later Set or Delete.
+// Once a key's last pending heap entry has been popped and it has no
+// current data (i.e. nothing could still reference its generation
+// counter), its generation entry is pruned so long-running processes with
+// key churn don't accumulate one permanently per ever-TTL'd key.
+func (s *Store) sweepExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for len(s.expiry) > 0 && !s.expiry[0].expireAt.After(now) {
+		item := heap.Pop(&s.expiry).(*expiryItem)
+		if s.generation[item.key] == item.generation {
+			delete(s.data, item.key)
+		}
+
+		s.pending[item.key]--
+		if s.pending[item.key] <= 0 {
+			delete(s.pending, item.key)
+			s.pruneGenerationLocked(item.key)
+		}
+	}
+}
+
+// pruneGenerationLocked removes key's generation counter once it is no
+// longer live and no pending heap entry references it, so keys that have
+// had a TTL don't leave a permanent entry behind after they're deleted.
+// Callers must hold s.mu.
+func (s *Store) pruneGenerationLocked(key string) {
+	if s.pending[key] > 0 {
+		return
+	}
+	if _, live := s.data[key]; live {
+		return
+	}
+	delete(s.generation, key)
+}
@@ -0,0 +1,263 @@
+package memory
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/basperheim/golang-key-value/internal/storage"
+)
+
+// TestGetTreatsExpiredAsMissingBeforeSweep verifies that Get reports a key
+// as missing once its TTL has passed, even though the background
+// expiryLoop sweeper may not have gotten to it yet.
+func TestGetTreatsExpiredAsMissingBeforeSweep(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	if _, err := s.Set("k", "v", time.Millisecond); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok, err := s.Get("k"); err != nil || ok {
+		t.Fatalf("Get after expiry = (ok=%v, err=%v), want (false, nil)", ok, err)
+	}
+}
+
+// TestSetInvalidatesStaleHeapEntryByGeneration verifies that overwriting a
+// key with a longer TTL does not let the original (now-stale) heap entry
+// delete the new value when its old deadline comes around: the generation
+// counter must make the sweeper recognize the old entry as superseded.
+func TestSetInvalidatesStaleHeapEntryByGeneration(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	if _, err := s.Set("k", "first", 5*time.Millisecond); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	// Supersede the short TTL with a much longer one before it fires.
+	if _, err := s.Set("k", "second", time.Hour); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// Wait past the original (superseded) deadline and give the sweeper a
+	// chance to run; the stale heap entry must be discarded rather than
+	// deleting the current value.
+	time.Sleep(20 * time.Millisecond)
+
+	entry, ok, err := s.Get("k")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !ok {
+		t.Fatal("Get reported key missing; stale heap entry deleted the superseding value")
+	}
+	if entry.Value != "second" {
+		t.Fatalf("Get value = %q, want %q", entry.Value, "second")
+	}
+}
+
+// TestDeleteInvalidatesStaleHeapEntry verifies that deleting and then
+// recreating a key with a TTL does not let the original key's stale heap
+// entry delete the new entry.
+func TestDeleteInvalidatesStaleHeapEntry(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	if _, err := s.Set("k", "first", 5*time.Millisecond); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := s.Delete("k"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Set("k", "second", 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	entry, ok, err := s.Get("k")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !ok || entry.Value != "second" {
+		t.Fatalf("Get = (entry=%+v, ok=%v), want (\"second\", true)", entry, ok)
+	}
+}
+
+// TestGenerationPrunedAfterExpiry verifies that a key's generation counter
+// is cleaned up once its TTL fires and it's gone, rather than left behind
+// forever, since long-running processes with key churn would otherwise
+// accumulate one generation entry per ever-TTL'd key.
+func TestGenerationPrunedAfterExpiry(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	if _, err := s.Set("k", "v", 5*time.Millisecond); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	// Give the sweeper a moment to run; expiryLoop wakes up on its own.
+	s.sweepExpired()
+
+	s.mu.RLock()
+	_, stillTracked := s.generation["k"]
+	s.mu.RUnlock()
+	if stillTracked {
+		t.Fatal("generation[\"k\"] still present after expiry; want pruned")
+	}
+}
+
+// TestGenerationPrunedAfterDelete verifies that deleting a key that had a
+// TTL prunes its generation counter once the stale heap entry is also
+// popped, rather than leaking it permanently.
+func TestGenerationPrunedAfterDelete(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	if _, err := s.Set("k", "v", time.Hour); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := s.Delete("k"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	// The heap entry for the deleted key is still pending until popped;
+	// pop it directly to simulate the sweeper eventually reaching it.
+	s.mu.Lock()
+	for len(s.expiry) > 0 {
+		item := heap.Pop(&s.expiry).(*expiryItem)
+		s.pending[item.key]--
+		if s.pending[item.key] <= 0 {
+			delete(s.pending, item.key)
+			s.pruneGenerationLocked(item.key)
+		}
+	}
+	_, stillTracked := s.generation["k"]
+	s.mu.Unlock()
+	if stillTracked {
+		t.Fatal("generation[\"k\"] still present after delete and heap drain; want pruned")
+	}
+}
+
+// TestCompareAndSwap verifies the happy path, a value mismatch, and a
+// missing key each return the documented result.
+func TestCompareAndSwap(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	if _, err := s.Set("k", "v1", 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	entry, ok, err := s.CompareAndSwap("k", "v1", "v2", 0)
+	if err != nil || !ok || entry.Value != "v2" {
+		t.Fatalf("CompareAndSwap(match) = (%+v, %v, %v), want (\"v2\", true, nil)", entry, ok, err)
+	}
+
+	if _, ok, err := s.CompareAndSwap("k", "v1", "v3", 0); ok || !errors.Is(err, storage.ErrPreconditionFailed) {
+		t.Fatalf("CompareAndSwap(stale prevValue) = (ok=%v, err=%v), want (false, ErrPreconditionFailed)", ok, err)
+	}
+
+	if _, ok, err := s.CompareAndSwap("missing", "v1", "v2", 0); ok || !errors.Is(err, storage.ErrKeyNotFound) {
+		t.Fatalf("CompareAndSwap(missing key) = (ok=%v, err=%v), want (false, ErrKeyNotFound)", ok, err)
+	}
+}
+
+// TestCompareAndDelete verifies the happy path, a value mismatch, and a
+// missing key each return the documented result.
+func TestCompareAndDelete(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	if _, err := s.Set("k", "v1", 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	if ok, err := s.CompareAndDelete("k", "wrong"); ok || !errors.Is(err, storage.ErrPreconditionFailed) {
+		t.Fatalf("CompareAndDelete(mismatch) = (ok=%v, err=%v), want (false, ErrPreconditionFailed)", ok, err)
+	}
+
+	ok, err := s.CompareAndDelete("k", "v1")
+	if err != nil || !ok {
+		t.Fatalf("CompareAndDelete(match) = (%v, %v), want (true, nil)", ok, err)
+	}
+
+	if ok, err := s.CompareAndDelete("k", "v1"); ok || !errors.Is(err, storage.ErrKeyNotFound) {
+		t.Fatalf("CompareAndDelete(already gone) = (ok=%v, err=%v), want (false, ErrKeyNotFound)", ok, err)
+	}
+}
+
+// TestCreateIfAbsent verifies that it succeeds once and then reports
+// ErrKeyExists on a second call for the same key.
+func TestCreateIfAbsent(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	entry, ok, err := s.CreateIfAbsent("k", "v1", 0)
+	if err != nil || !ok || entry.Value != "v1" {
+		t.Fatalf("CreateIfAbsent(absent) = (%+v, %v, %v), want (\"v1\", true, nil)", entry, ok, err)
+	}
+
+	if _, ok, err := s.CreateIfAbsent("k", "v2", 0); ok || !errors.Is(err, storage.ErrKeyExists) {
+		t.Fatalf("CreateIfAbsent(already present) = (ok=%v, err=%v), want (false, ErrKeyExists)", ok, err)
+	}
+}
+
+// TestUpdateIfExists verifies that it reports ErrKeyNotFound for an absent
+// key and otherwise overwrites the value.
+func TestUpdateIfExists(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	if _, ok, err := s.UpdateIfExists("missing", "v", 0); ok || !errors.Is(err, storage.ErrKeyNotFound) {
+		t.Fatalf("UpdateIfExists(absent) = (ok=%v, err=%v), want (false, ErrKeyNotFound)", ok, err)
+	}
+
+	if _, err := s.Set("k", "v1", 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	entry, ok, err := s.UpdateIfExists("k", "v2", 0)
+	if err != nil || !ok || entry.Value != "v2" {
+		t.Fatalf("UpdateIfExists(present) = (%+v, %v, %v), want (\"v2\", true, nil)", entry, ok, err)
+	}
+}
+
+// TestCompareAndSwapConcurrentOnlyOneWinner verifies that when many
+// goroutines race a CompareAndSwap against the same expected prevValue,
+// exactly one succeeds and the rest observe a precondition failure - the
+// store's locking must serialize the check-and-set.
+func TestCompareAndSwapConcurrentOnlyOneWinner(t *testing.T) {
+	s := New()
+	defer s.Close()
+
+	if _, err := s.Set("k", "initial", 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	const n = 50
+	var successes int32
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			if _, ok, _ := s.CompareAndSwap("k", "initial", "updated", 0); ok {
+				atomic.AddInt32(&successes, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if successes != 1 {
+		t.Fatalf("successful CompareAndSwap count = %d, want exactly 1", successes)
+	}
+	entry, ok, err := s.Get("k")
+	if err != nil || !ok || entry.Value != "updated" {
+		t.Fatalf("Get after race = (%+v, %v, %v), want (\"updated\", true, nil)", entry, ok, err)
+	}
+}
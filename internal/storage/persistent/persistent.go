@@ -0,0 +1,460 @@
+// Package persistent implements storage.Store on top of storage/memory by
+// journaling every mutation to a write-ahead log and periodically
+// compacting it into a snapshot, so the store's contents survive a
+// restart. It also implements storage.Snapshotter.
+package persistent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/basperheim/golang-key-value/internal/storage"
+	"github.com/basperheim/golang-key-value/internal/storage/memory"
+	"github.com/basperheim/golang-key-value/internal/storage/wal"
+)
+
+const (
+	walFileName = "data.wal"
+
+	// snapshotEveryOps and snapshotEveryBytes bound how much WAL a crash
+	// can force us to replay: whichever threshold is hit first triggers a
+	// background snapshot.
+	snapshotEveryOps   = 1000
+	snapshotEveryBytes = 4 << 20 // 4 MiB
+)
+
+// snapshotFile is the JSON shape written to snapshot.<index>.json.
+type snapshotFile struct {
+	Index   uint64          `json:"index"`
+	Entries []storage.Entry `json:"entries"`
+}
+
+// Store is a durable storage.Store backed by an in-memory store, a
+// write-ahead log, and periodic snapshots.
+type Store struct {
+	dir     string
+	walPath string
+
+	mu  sync.Mutex
+	wal *wal.Writer
+
+	inner *memory.Store
+
+	opsSinceSnapshot   int
+	bytesSinceSnapshot int
+	lastSnapshotPath   string
+
+	snapshotCh chan struct{}
+	done       chan struct{}
+	closeOnce  sync.Once
+}
+
+// Open loads any existing snapshot and WAL tail from dir (creating dir if
+// necessary), replays them into an in-memory store, and returns a Store
+// ready to accept new mutations.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	snapIndex, snapEntries, snapPath, err := loadLatestSnapshot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	walPath := filepath.Join(dir, walFileName)
+	records, err := wal.ReadAll(walPath)
+	if err != nil {
+		return nil, err
+	}
+
+	state := make(map[string]storage.Entry, len(snapEntries))
+	for _, e := range snapEntries {
+		state[e.Key] = e
+	}
+
+	finalIndex := snapIndex
+	now := time.Now()
+	for _, rec := range records {
+		if rec.Index <= snapIndex {
+			continue
+		}
+		finalIndex = rec.Index
+
+		switch rec.Op {
+		case wal.OpDelete:
+			delete(state, rec.Key)
+		case wal.OpSet, wal.OpCAS:
+			entry := storage.Entry{
+				Key:       rec.Key,
+				Value:     rec.Value,
+				CreatedAt: time.Unix(0, rec.TS),
+				UpdatedAt: time.Unix(0, rec.TS),
+			}
+			if rec.TTL != "" {
+				if ttl, err := time.ParseDuration(rec.TTL); err == nil && ttl > 0 {
+					expireAt := entry.CreatedAt.Add(ttl)
+					entry.ExpireTime = &expireAt
+				}
+			}
+			if entry.Expired(now) {
+				delete(state, rec.Key)
+				continue
+			}
+			state[rec.Key] = entry
+		}
+	}
+
+	entries := make([]storage.Entry, 0, len(state))
+	for _, e := range state {
+		entries = append(entries, e)
+	}
+
+	inner := memory.New()
+	inner.Restore(entries, finalIndex)
+
+	w, err := wal.Open(walPath)
+	if err != nil {
+		inner.Close()
+		return nil, err
+	}
+
+	s := &Store{
+		dir:              dir,
+		walPath:          walPath,
+		wal:              w,
+		inner:            inner,
+		lastSnapshotPath: snapPath,
+		snapshotCh:       make(chan struct{}, 1),
+		done:             make(chan struct{}),
+	}
+	go s.snapshotLoop()
+	return s, nil
+}
+
+// Close flushes and closes the WAL and stops the store's background
+// snapshotter.
+func (s *Store) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.wal.Close(); err != nil {
+		return err
+	}
+	return s.inner.Close()
+}
+
+// Index returns the store's current mutation index.
+func (s *Store) Index() uint64 { return s.inner.Index() }
+
+// Get retrieves a value from the store based on the given key.
+func (s *Store) Get(key string) (storage.Entry, bool, error) { return s.inner.Get(key) }
+
+// Watch subscribes to key mutations; see storage.EventBroker.Watch.
+func (s *Store) Watch(ctx context.Context, keyPrefix string, recursive bool, sinceIndex uint64) (<-chan storage.Event, error) {
+	return s.inner.Watch(ctx, keyPrefix, recursive, sinceIndex)
+}
+
+// Set creates or overwrites key's entry, durably. The mutation is
+// journaled (and fsynced) before it is applied to the in-memory store, so
+// a journal failure leaves the store exactly as it was.
+func (s *Store) Set(key, value string, ttl time.Duration) (storage.Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.journalLocked(wal.OpSet, key, value, ttl, s.inner.Index()+1); err != nil {
+		return storage.Entry{}, err
+	}
+	entry, err := s.inner.Set(key, value, ttl)
+	if err != nil {
+		return entry, err
+	}
+	s.maybeSnapshotLocked()
+	return entry, nil
+}
+
+// Delete removes a key from the store, durably.
+func (s *Store) Delete(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok, err := s.inner.Get(key)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := s.journalLocked(wal.OpDelete, key, "", 0, s.inner.Index()+1); err != nil {
+		return false, err
+	}
+	ok, err = s.inner.Delete(key)
+	if err != nil || !ok {
+		return ok, err
+	}
+	s.maybeSnapshotLocked()
+	return true, nil
+}
+
+// CompareAndSwap sets key to newValue only if its current value equals
+// prevValue, durably. The precondition is checked (against s.inner, which
+// only this serialized Store mutates) before journaling, so the journal
+// never records a mutation that doesn't go on to apply cleanly.
+func (s *Store) CompareAndSwap(key, prevValue, newValue string, ttl time.Duration) (storage.Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok, err := s.inner.Get(key)
+	if err != nil {
+		return storage.Entry{}, false, err
+	}
+	if !ok {
+		return storage.Entry{}, false, storage.ErrKeyNotFound
+	}
+	if current.Value != prevValue {
+		return current, false, storage.ErrPreconditionFailed
+	}
+	if err := s.journalLocked(wal.OpCAS, key, newValue, ttl, s.inner.Index()+1); err != nil {
+		return storage.Entry{}, false, err
+	}
+	entry, ok, err := s.inner.CompareAndSwap(key, prevValue, newValue, ttl)
+	if err != nil || !ok {
+		return entry, ok, err
+	}
+	s.maybeSnapshotLocked()
+	return entry, true, nil
+}
+
+// CompareAndDelete removes key only if its current value equals prevValue,
+// durably.
+func (s *Store) CompareAndDelete(key, prevValue string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok, err := s.inner.Get(key)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, storage.ErrKeyNotFound
+	}
+	if current.Value != prevValue {
+		return false, storage.ErrPreconditionFailed
+	}
+	if err := s.journalLocked(wal.OpDelete, key, "", 0, s.inner.Index()+1); err != nil {
+		return false, err
+	}
+	ok, err = s.inner.CompareAndDelete(key, prevValue)
+	if err != nil || !ok {
+		return ok, err
+	}
+	s.maybeSnapshotLocked()
+	return true, nil
+}
+
+// CreateIfAbsent sets key to value only if it does not already exist,
+// durably.
+func (s *Store) CreateIfAbsent(key, value string, ttl time.Duration) (storage.Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok, err := s.inner.Get(key)
+	if err != nil {
+		return storage.Entry{}, false, err
+	}
+	if ok {
+		return storage.Entry{}, false, storage.ErrKeyExists
+	}
+	if err := s.journalLocked(wal.OpCAS, key, value, ttl, s.inner.Index()+1); err != nil {
+		return storage.Entry{}, false, err
+	}
+	entry, ok, err := s.inner.CreateIfAbsent(key, value, ttl)
+	if err != nil || !ok {
+		return entry, ok, err
+	}
+	s.maybeSnapshotLocked()
+	return entry, true, nil
+}
+
+// UpdateIfExists sets key to value only if it already exists, durably.
+func (s *Store) UpdateIfExists(key, value string, ttl time.Duration) (storage.Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok, err := s.inner.Get(key)
+	if err != nil {
+		return storage.Entry{}, false, err
+	}
+	if !ok {
+		return storage.Entry{}, false, storage.ErrKeyNotFound
+	}
+	if err := s.journalLocked(wal.OpCAS, key, value, ttl, s.inner.Index()+1); err != nil {
+		return storage.Entry{}, false, err
+	}
+	entry, ok, err := s.inner.UpdateIfExists(key, value, ttl)
+	if err != nil || !ok {
+		return entry, ok, err
+	}
+	s.maybeSnapshotLocked()
+	return entry, true, nil
+}
+
+// journalLocked appends a WAL record for a mutation about to be applied to
+// s.inner, fsyncing before returning. Callers must hold s.mu and must call
+// this before mutating s.inner, so a journal failure never leaves a
+// mutation visible that didn't make it to durable storage.
+func (s *Store) journalLocked(op wal.Op, key, value string, ttl time.Duration, index uint64) error {
+	ttlStr := ""
+	if ttl > 0 {
+		ttlStr = ttl.String()
+	}
+	rec := wal.Record{
+		Op:    op,
+		Key:   key,
+		Value: value,
+		TTL:   ttlStr,
+		TS:    time.Now().UnixNano(),
+		Index: index,
+	}
+	if err := s.wal.Append(rec); err != nil {
+		return err
+	}
+	s.opsSinceSnapshot++
+	s.bytesSinceSnapshot += len(value) + len(key)
+	return nil
+}
+
+// maybeSnapshotLocked signals the background snapshotter if enough WAL has
+// accumulated since the last snapshot. Callers must hold s.mu.
+func (s *Store) maybeSnapshotLocked() {
+	if s.opsSinceSnapshot < snapshotEveryOps && s.bytesSinceSnapshot < snapshotEveryBytes {
+		return
+	}
+	select {
+	case s.snapshotCh <- struct{}{}:
+	default:
+	}
+}
+
+// snapshotLoop takes a snapshot every time it is signaled, until Close.
+func (s *Store) snapshotLoop() {
+	for {
+		select {
+		case <-s.snapshotCh:
+			if _, _, err := s.Snapshot(); err != nil {
+				log.Printf("persistent: background snapshot failed: %v", err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Snapshot writes a new point-in-time snapshot of the store's contents and
+// truncates the WAL up to that point, satisfying storage.Snapshotter. It
+// may also be called directly (e.g. from an admin endpoint) to force an
+// out-of-band snapshot.
+func (s *Store) Snapshot() (string, uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshotLocked()
+}
+
+func (s *Store) snapshotLocked() (string, uint64, error) {
+	index := s.inner.Index()
+	payload, err := json.Marshal(snapshotFile{Index: index, Entries: s.inner.Entries()})
+	if err != nil {
+		return "", 0, err
+	}
+
+	finalPath := filepath.Join(s.dir, fmt.Sprintf("snapshot.%d.json", index))
+	tmpPath := finalPath + ".tmp"
+
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := f.Write(payload); err != nil {
+		f.Close()
+		return "", 0, err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return "", 0, err
+	}
+	if err := f.Close(); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		return "", 0, err
+	}
+
+	if err := s.wal.TruncateBefore(index); err != nil {
+		return finalPath, index, err
+	}
+
+	s.opsSinceSnapshot = 0
+	s.bytesSinceSnapshot = 0
+	s.lastSnapshotPath = finalPath
+	return finalPath, index, nil
+}
+
+// LatestSnapshotPath returns the path of the most recent snapshot, or ""
+// if none has been taken yet.
+func (s *Store) LatestSnapshotPath() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastSnapshotPath, nil
+}
+
+// loadLatestSnapshot scans dir for the snapshot.<index>.json with the
+// highest index and loads it, returning a zero index and no entries if
+// none exists.
+func loadLatestSnapshot(dir string) (uint64, []storage.Entry, string, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil, "", nil
+		}
+		return 0, nil, "", err
+	}
+
+	var bestIndex uint64
+	var bestPath string
+	for _, f := range files {
+		name := f.Name()
+		if !strings.HasPrefix(name, "snapshot.") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		raw := strings.TrimSuffix(strings.TrimPrefix(name, "snapshot."), ".json")
+		index, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			continue
+		}
+		if bestPath == "" || index > bestIndex {
+			bestIndex = index
+			bestPath = name
+		}
+	}
+	if bestPath == "" {
+		return 0, nil, "", nil
+	}
+
+	fullPath := filepath.Join(dir, bestPath)
+	raw, err := os.ReadFile(fullPath)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	var snap snapshotFile
+	if err := json.Unmarshal(raw, &snap); err != nil {
+		return 0, nil, "", err
+	}
+	sort.Slice(snap.Entries, func(i, j int) bool { return snap.Entries[i].Key < snap.Entries[j].Key })
+	return snap.Index, snap.Entries, fullPath, nil
+}
@@ -0,0 +1,84 @@
+// Package storage defines the pluggable key/value storage contract and the
+// wire types shared by every backend (in-memory, bolt-backed, ...).
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Sentinel errors returned by the compare-and-swap family of operations.
+// Implementations must return these exact values (or wrap them) so callers
+// can use errors.Is regardless of backend.
+var (
+	ErrKeyNotFound         = errors.New("key not found")
+	ErrKeyExists           = errors.New("key already exists")
+	ErrPreconditionFailed  = errors.New("precondition failed")
+	ErrSnapshotUnsupported = errors.New("backend does not support snapshots")
+)
+
+// Entry represents a key/value entry with metadata. ExpireTime is a
+// pointer so that entries with no TTL marshal without an expireTime field
+// at all: omitempty is a no-op on a non-pointer time.Time, which would
+// otherwise leak the zero time to clients.
+type Entry struct {
+	Key        string     `json:"key"`
+	Value      string     `json:"value"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	ExpireTime *time.Time `json:"expireTime,omitempty"`
+}
+
+// Expired reports whether the entry's TTL has passed as of now.
+func (e Entry) Expired(now time.Time) bool {
+	return e.ExpireTime != nil && now.After(*e.ExpireTime)
+}
+
+// EventAction identifies the kind of mutation an Event reports.
+type EventAction string
+
+const (
+	EventSet    EventAction = "set"
+	EventDelete EventAction = "delete"
+	EventCAS    EventAction = "cas"
+)
+
+// Event describes a single mutation, delivered to watchers via Watch.
+type Event struct {
+	Action    EventAction `json:"action"`
+	Key       string      `json:"key"`
+	Value     string      `json:"value,omitempty"`
+	PrevValue string      `json:"prevValue,omitempty"`
+	Index     uint64      `json:"index"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// Store is the storage backend contract the service layer depends on.
+// Implementations live under internal/storage/<backend> and are wired up
+// by cmd/server based on configuration; memory.Store and bolt.Store are
+// the two shipped today.
+type Store interface {
+	Set(key, value string, ttl time.Duration) (Entry, error)
+	Get(key string) (Entry, bool, error)
+	Delete(key string) (bool, error)
+	CompareAndSwap(key, prevValue, newValue string, ttl time.Duration) (Entry, bool, error)
+	CompareAndDelete(key, prevValue string) (bool, error)
+	CreateIfAbsent(key, value string, ttl time.Duration) (Entry, bool, error)
+	UpdateIfExists(key, value string, ttl time.Duration) (Entry, bool, error)
+	Watch(ctx context.Context, keyPrefix string, recursive bool, sinceIndex uint64) (<-chan Event, error)
+	Index() uint64
+	Close() error
+}
+
+// Snapshotter is an optional capability implemented by durable backends
+// that can write a full point-in-time copy of their data to disk (e.g.
+// for backup). Callers should type-assert a Store to Snapshotter and
+// treat ErrSnapshotUnsupported as "backend doesn't support this" rather
+// than a hard failure.
+type Snapshotter interface {
+	// Snapshot writes a new snapshot and returns its path and index.
+	Snapshot() (path string, index uint64, err error)
+	// LatestSnapshotPath returns the path of the most recent snapshot.
+	LatestSnapshotPath() (string, error)
+}
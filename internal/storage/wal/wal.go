@@ -0,0 +1,163 @@
+// Package wal implements a simple length-prefixed, JSON-record
+// write-ahead log used by storage/persistent to make the in-memory store
+// durable across restarts.
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+// Op identifies the kind of mutation a Record replays.
+type Op string
+
+const (
+	OpSet    Op = "set"
+	OpDelete Op = "delete"
+	OpCAS    Op = "cas"
+)
+
+// Record is a single WAL entry. TTL is the duration string the mutation
+// was created with (empty if the key never expires); combined with TS it
+// lets replay recompute the entry's remaining TTL.
+type Record struct {
+	Op    Op     `json:"op"`
+	Key   string `json:"key"`
+	Value string `json:"value,omitempty"`
+	TTL   string `json:"ttl,omitempty"`
+	TS    int64  `json:"ts"`
+	Index uint64 `json:"index"`
+}
+
+// Writer appends Records to a log file, fsyncing after every write so a
+// caller can safely acknowledge the mutation once Append returns.
+type Writer struct {
+	path string
+	f    *os.File
+}
+
+// Open opens path for appending, creating it if it does not exist.
+func Open(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{path: path, f: f}, nil
+}
+
+// Append writes rec as a length-prefixed JSON record and fsyncs before
+// returning.
+func (w *Writer) Append(rec Record) error {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(payload)))
+	if _, err := w.f.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.f.Write(payload); err != nil {
+		return err
+	}
+	return w.f.Sync()
+}
+
+// Close closes the underlying file.
+func (w *Writer) Close() error {
+	return w.f.Close()
+}
+
+// TruncateBefore rewrites the log to keep only records with Index >
+// upToIndex, called once a snapshot has made the earlier records
+// redundant.
+func (w *Writer) TruncateBefore(upToIndex uint64) error {
+	kept, err := ReadAll(w.path)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := w.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	for _, rec := range kept {
+		if rec.Index <= upToIndex {
+			continue
+		}
+		payload, err := json.Marshal(rec)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		header := make([]byte, 4)
+		binary.BigEndian.PutUint32(header, uint32(len(payload)))
+		if _, err := tmp.Write(header); err != nil {
+			tmp.Close()
+			return err
+		}
+		if _, err := tmp.Write(payload); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, w.path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w.f = f
+	return nil
+}
+
+// ReadAll reads every complete length-prefixed record from path in order.
+// A missing file yields an empty slice. A truncated trailing record (as
+// could be left by a crash mid-write) is discarded rather than treated as
+// an error.
+func ReadAll(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	r := bufio.NewReader(f)
+	for {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(r, header); err != nil {
+			break
+		}
+		size := binary.BigEndian.Uint32(header)
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			break
+		}
+		var rec Record
+		if err := json.Unmarshal(payload, &rec); err != nil {
+			break
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
@@ -0,0 +1,133 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/basperheim/golang-key-value/internal/apierror"
+)
+
+type contextKey int
+
+const requestIDKey contextKey = 0
+
+// withRequestID assigns each request a short random ID, attaches it to the
+// request context and echoes it back via X-Request-Id so client and
+// server logs can be correlated.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := newRequestID()
+		w.Header().Set("X-Request-Id", id)
+		ctx := context.WithValue(r.Context(), requestIDKey, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func newRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buf)
+}
+
+// requestID extracts the ID set by withRequestID, if any.
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey).(string)
+	return id
+}
+
+// statusRecorder captures the status code written by the wrapped handler
+// so withLogging can report it after the fact.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Flush passes through to the wrapped ResponseWriter's http.Flusher, if it
+// has one, so streaming handlers (e.g. SSE) still work once wrapped in a
+// statusRecorder. Embedding alone doesn't promote Flush, since it isn't
+// part of the http.ResponseWriter interface.
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack passes through to the wrapped ResponseWriter's http.Hijacker, for
+// the same reason as Flush above.
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("underlying ResponseWriter does not support Hijack")
+	}
+	return hijacker.Hijack()
+}
+
+// withLogging logs method, path, status and duration for every request.
+func withLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		log.Printf("[%s] %s %s %d %s", requestID(r), r.Method, r.URL.Path, rec.status, time.Since(start))
+	})
+}
+
+// withRecovery turns a panic in a handler into a 500 apierror response
+// instead of crashing the server.
+func withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				log.Printf("[%s] panic: %v", requestID(r), rec)
+				apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Internal server error", "", 0)
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// jsonWriter defaults the response Content-Type to application/json if the
+// wrapped handler hasn't set one of its own before writing.
+type jsonWriter struct {
+	http.ResponseWriter
+	headerWritten bool
+}
+
+func (w *jsonWriter) WriteHeader(status int) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.headerWritten = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *jsonWriter) Write(b []byte) (int, error) {
+	if !w.headerWritten {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// withJSONContentType defaults a handler's response to JSON. It is only
+// mounted on endpoints that always return JSON (or an apierror body) -
+// /set mixes plain-text and JSON responses and sets its own headers
+// explicitly, so it is not wrapped with this middleware.
+func withJSONContentType(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&jsonWriter{ResponseWriter: w}, r)
+	})
+}
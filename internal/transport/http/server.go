@@ -0,0 +1,354 @@
+// Package http is the HTTP transport for the key/value store: it adapts
+// kvstoreservice.Service to the wire format clients already depend on
+// (query-param and JSON request bodies, apierror JSON error bodies) and
+// wraps the whole thing with request-scoped middleware.
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/basperheim/golang-key-value/internal/apierror"
+	"github.com/basperheim/golang-key-value/internal/service/kvstoreservice"
+	"github.com/basperheim/golang-key-value/internal/storage"
+)
+
+// Server exposes the key/value HTTP API over a kvstoreservice.Service.
+type Server struct {
+	svc *kvstoreservice.Service
+}
+
+// NewServer wires svc into a ready-to-serve Server.
+func NewServer(svc *kvstoreservice.Service) *Server {
+	return &Server{svc: svc}
+}
+
+// Handler returns the fully composed http.Handler, including middleware.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/set", s.handleSet)
+	mux.Handle("/get", withJSONContentType(http.HandlerFunc(s.handleGet)))
+	mux.Handle("/delete", withJSONContentType(http.HandlerFunc(s.handleDelete)))
+	mux.Handle("/cas", withJSONContentType(http.HandlerFunc(s.handleCAS)))
+	mux.Handle("/cad", withJSONContentType(http.HandlerFunc(s.handleCAD)))
+	mux.HandleFunc("/watch", s.handleWatch)
+	mux.HandleFunc("/admin/snapshot", s.handleAdminSnapshot)
+
+	return withRequestID(withRecovery(withLogging(mux)))
+}
+
+// deleteResponse is the JSON body returned by /delete and /cad.
+type deleteResponse struct {
+	Key string `json:"key"`
+}
+
+func (s *Server) handleSet(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		key := r.URL.Query().Get("key")
+		value := r.URL.Query().Get("value")
+		entry, err := s.svc.Set(key, value, r.URL.Query().Get("ttl"))
+		if err != nil {
+			writeServiceErr(w, s.svc.Index(), err)
+			return
+		}
+		fmt.Fprintf(w, "Key %s set to value %s\n", entry.Key, entry.Value)
+
+	case http.MethodPost:
+		var data map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidBody, "Failed to decode JSON data", "body", s.svc.Index())
+			return
+		}
+		key, _ := data["key"].(string)
+		value, ok := data["value"].(string)
+		if !ok {
+			// If value is not a string, assume it's a JSON object and marshal it
+			jsonValue, err := json.Marshal(data["value"])
+			if err != nil {
+				apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Failed to marshal JSON value", "value", s.svc.Index())
+				return
+			}
+			value = string(jsonValue)
+		}
+		ttlRaw, _ := data["ttl"].(string)
+		if _, err := s.svc.Set(key, value, ttlRaw); err != nil {
+			writeServiceErr(w, s.svc.Index(), err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "ok\n")
+
+	default:
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed", "", s.svc.Index())
+	}
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	entry, ok, err := s.svc.Get(key)
+	if err != nil {
+		writeServiceErr(w, s.svc.Index(), err)
+		return
+	}
+	if !ok {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeKeyNotFound, "Key not found", "key", s.svc.Index())
+		return
+	}
+
+	var jsonValue interface{}
+	if err := json.Unmarshal([]byte(entry.Value), &jsonValue); err != nil {
+		// If it's not a valid JSON object, treat it as a string
+		jsonValue = entry.Value
+	}
+	writeJSON(w, map[string]interface{}{
+		"key":       entry.Key,
+		"value":     jsonValue,
+		"createdAt": entry.CreatedAt,
+		"updatedAt": entry.UpdatedAt,
+	})
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	ok, err := s.svc.Delete(key)
+	if err != nil {
+		writeServiceErr(w, s.svc.Index(), err)
+		return
+	}
+	if !ok {
+		apierror.Write(w, http.StatusNotFound, apierror.CodeKeyNotFound, "Key not found", "key", s.svc.Index())
+		return
+	}
+	writeJSON(w, deleteResponse{Key: key})
+}
+
+// casRequest is the JSON body accepted by /cas.
+type casRequest struct {
+	Key       string `json:"key"`
+	PrevValue string `json:"prevValue"`
+	Value     string `json:"value"`
+	TTL       string `json:"ttl"`
+	PrevExist *bool  `json:"prevExist"`
+}
+
+func (s *Server) handleCAS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed", "", s.svc.Index())
+		return
+	}
+
+	var req casRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidBody, "Failed to decode JSON data", "body", s.svc.Index())
+		return
+	}
+
+	var entry storage.Entry
+	var ok bool
+	var err error
+	switch {
+	case req.PrevExist != nil && !*req.PrevExist:
+		entry, ok, err = s.svc.CreateIfAbsent(req.Key, req.Value, req.TTL)
+	case req.PrevExist != nil && *req.PrevExist:
+		entry, ok, err = s.svc.UpdateIfExists(req.Key, req.Value, req.TTL)
+	default:
+		entry, ok, err = s.svc.CompareAndSwap(req.Key, req.PrevValue, req.Value, req.TTL)
+	}
+	if !ok {
+		writeServiceErr(w, s.svc.Index(), err)
+		return
+	}
+	writeJSON(w, entry)
+}
+
+// cadRequest is the JSON body accepted by /cad.
+type cadRequest struct {
+	Key       string `json:"key"`
+	PrevValue string `json:"prevValue"`
+}
+
+func (s *Server) handleCAD(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed", "", s.svc.Index())
+		return
+	}
+
+	var req cadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidBody, "Failed to decode JSON data", "body", s.svc.Index())
+		return
+	}
+
+	ok, err := s.svc.CompareAndDelete(req.Key, req.PrevValue)
+	if !ok {
+		writeServiceErr(w, s.svc.Index(), err)
+		return
+	}
+	writeJSON(w, deleteResponse{Key: req.Key})
+}
+
+// handleWatch negotiates between a single-event long-poll response and a
+// text/event-stream response via Accept.
+func (s *Server) handleWatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed", "", s.svc.Index())
+		return
+	}
+
+	keyPrefix := r.URL.Query().Get("key")
+	recursive := r.URL.Query().Get("recursive") == "true"
+	wait := r.URL.Query().Get("wait") != "false"
+
+	var since uint64
+	if raw := r.URL.Query().Get("since"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidBody, "Invalid since value", "since", s.svc.Index())
+			return
+		}
+		since = parsed
+	}
+
+	ctx := r.Context()
+	events, err := s.svc.Watch(ctx, keyPrefix, recursive, since)
+	if err != nil {
+		writeServiceErr(w, s.svc.Index(), err)
+		return
+	}
+
+	if r.Header.Get("Accept") == "text/event-stream" {
+		s.streamWatch(w, ctx, events)
+		return
+	}
+
+	if !wait {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				apierror.Write(w, http.StatusNotFound, apierror.CodeKeyNotFound, "No event available", "key", s.svc.Index())
+				return
+			}
+			writeJSON(w, e)
+		default:
+			apierror.Write(w, http.StatusNotFound, apierror.CodeKeyNotFound, "No event available", "key", s.svc.Index())
+		}
+		return
+	}
+
+	select {
+	case e, ok := <-events:
+		if !ok {
+			apierror.Write(w, http.StatusNotFound, apierror.CodeKeyNotFound, "No event available", "key", s.svc.Index())
+			return
+		}
+		writeJSON(w, e)
+	case <-ctx.Done():
+		// Client disconnected while waiting; nothing left to write.
+	}
+}
+
+func (s *Server) streamWatch(w http.ResponseWriter, ctx context.Context, events <-chan storage.Event) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Streaming unsupported", "", s.svc.Index())
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			payload, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", payload)
+			flusher.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// snapshotResponse is the JSON body returned by POST /admin/snapshot.
+type snapshotResponse struct {
+	Path  string `json:"path"`
+	Index uint64 `json:"index"`
+}
+
+// handleAdminSnapshot triggers a snapshot (POST) or downloads the most
+// recent one (GET). Both return storage.ErrSnapshotUnsupported for
+// backends that don't implement storage.Snapshotter (e.g. memory, bolt).
+func (s *Server) handleAdminSnapshot(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		path, index, err := s.svc.Snapshot()
+		if err != nil {
+			writeServiceErr(w, s.svc.Index(), err)
+			return
+		}
+		writeJSON(w, snapshotResponse{Path: path, Index: index})
+
+	case http.MethodGet:
+		path, err := s.svc.LatestSnapshotPath()
+		if err != nil {
+			writeServiceErr(w, s.svc.Index(), err)
+			return
+		}
+		if path == "" {
+			apierror.Write(w, http.StatusNotFound, apierror.CodeKeyNotFound, "No snapshot has been taken yet", "", s.svc.Index())
+			return
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(path)))
+		http.ServeFile(w, r, path)
+
+	default:
+		apierror.Write(w, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed", "", s.svc.Index())
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, "Error encoding JSON", "", 0)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+// writeServiceErr maps a kvstoreservice/storage error to the HTTP status
+// and apierror code the endpoints above respond with.
+func writeServiceErr(w http.ResponseWriter, index uint64, err error) {
+	switch {
+	case errors.Is(err, kvstoreservice.ErrMissingKey):
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeMissingKey, err.Error(), "key", index)
+	case errors.Is(err, kvstoreservice.ErrMissingValue):
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeMissingValue, err.Error(), "value", index)
+	case errors.Is(err, kvstoreservice.ErrInvalidTTL):
+		apierror.Write(w, http.StatusBadRequest, apierror.CodeInvalidTTL, err.Error(), "ttl", index)
+	case errors.Is(err, storage.ErrKeyNotFound):
+		apierror.Write(w, http.StatusNotFound, apierror.CodeKeyNotFound, err.Error(), "key", index)
+	case errors.Is(err, storage.ErrKeyExists):
+		apierror.Write(w, http.StatusPreconditionFailed, apierror.CodeKeyExists, err.Error(), "key", index)
+	case errors.Is(err, storage.ErrPreconditionFailed):
+		apierror.Write(w, http.StatusPreconditionFailed, apierror.CodeCASMismatch, err.Error(), "prevValue", index)
+	case errors.Is(err, storage.ErrSnapshotUnsupported):
+		apierror.Write(w, http.StatusNotImplemented, apierror.CodeNotImplemented, err.Error(), "backend", index)
+	default:
+		apierror.Write(w, http.StatusInternalServerError, apierror.CodeInternal, err.Error(), "", index)
+	}
+}
@@ -0,0 +1,178 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/basperheim/golang-key-value/internal/service/kvstoreservice"
+	"github.com/basperheim/golang-key-value/internal/storage/memory"
+)
+
+func newTestServer(t *testing.T) (http.Handler, func()) {
+	t.Helper()
+	store := memory.New()
+	svc := kvstoreservice.New(store)
+	return NewServer(svc).Handler(), func() { store.Close() }
+}
+
+// TestHandleSetAndGet verifies the basic GET /set -> GET /get round trip.
+func TestHandleSetAndGet(t *testing.T) {
+	handler, closeStore := newTestServer(t)
+	defer closeStore()
+
+	req := httptest.NewRequest(http.MethodGet, "/set?key=k&value=v", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/set status = %d, want 200; body: %s", rec.Code, rec.Body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/get?key=k", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("/get status = %d, want 200; body: %s", rec.Code, rec.Body)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if body["value"] != "v" {
+		t.Fatalf("value = %v, want %q", body["value"], "v")
+	}
+}
+
+// TestHandleGetMissingKeyReturnsApierror verifies a missing key produces
+// the apierror JSON envelope with the documented code and status.
+func TestHandleGetMissingKeyReturnsApierror(t *testing.T) {
+	handler, closeStore := newTestServer(t)
+	defer closeStore()
+
+	req := httptest.NewRequest(http.MethodGet, "/get?key=missing", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if body["errorCode"] != float64(300) {
+		t.Fatalf("errorCode = %v, want 300", body["errorCode"])
+	}
+}
+
+// TestHandleCASOmitsExpireTimeForNonTTLKey verifies /cas doesn't leak a
+// zero-value expireTime field for a key with no TTL, since it marshals
+// storage.Entry directly.
+func TestHandleCASOmitsExpireTimeForNonTTLKey(t *testing.T) {
+	handler, closeStore := newTestServer(t)
+	defer closeStore()
+
+	payload := strings.NewReader(`{"key":"k","prevExist":false,"value":"v"}`)
+	req := httptest.NewRequest(http.MethodPost, "/cas", payload)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body: %s", rec.Code, rec.Body)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if _, ok := body["expireTime"]; ok {
+		t.Fatalf("body unexpectedly has an expireTime field: %v", body)
+	}
+}
+
+// TestHandleWatchSSE verifies that a /watch request with
+// Accept: text/event-stream streams a matching event as an SSE "data:"
+// line instead of 500ing - this is the scenario that previously broke
+// because statusRecorder didn't promote http.Flusher.
+func TestHandleWatchSSE(t *testing.T) {
+	store := memory.New()
+	defer store.Close()
+	svc := kvstoreservice.New(store)
+	handler := NewServer(svc).Handler()
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reqURL, err := url.Parse(srv.URL + "/watch?key=k")
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL.String(), nil)
+	if err != nil {
+		t.Fatalf("NewRequestWithContext: %v", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	setReq, err := http.NewRequest(http.MethodGet, srv.URL+"/set?key=k&value=v", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if _, err := http.DefaultClient.Do(setReq); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading SSE stream: %v", err)
+		}
+		if strings.HasPrefix(line, "data: ") {
+			var event map[string]interface{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatalf("Unmarshal event: %v", err)
+			}
+			if event["key"] != "k" {
+				t.Fatalf("event key = %v, want %q", event["key"], "k")
+			}
+			return
+		}
+	}
+}
+
+// TestHandleSetMethodNotAllowed verifies an unsupported method on /set
+// returns the apierror envelope rather than the default net/http 405 page.
+func TestHandleSetMethodNotAllowed(t *testing.T) {
+	handler, closeStore := newTestServer(t)
+	defer closeStore()
+
+	req := httptest.NewRequest(http.MethodDelete, "/set?key=k&value=v", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want 405", rec.Code)
+	}
+}